@@ -0,0 +1,102 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+
+	"gopkg.in/yaml.v2"
+)
+
+// HubConfig describes a single SmartThings hub (account/location) to scrape.
+type HubConfig struct {
+	Name           string `yaml:"hub"`
+	OAuthClient    string `yaml:"oauth_client"`
+	OAuthTokenFile string `yaml:"oauth_token_file"`
+	IncludeDevices string `yaml:"include_devices"`
+	ExcludeDevices string `yaml:"exclude_devices"`
+
+	includeDevices *regexp.Regexp
+	excludeDevices *regexp.Regexp
+}
+
+// Config is the document loaded from `--config.file` describing one or more
+// SmartThings hubs to scrape.
+type Config struct {
+	Hubs []HubConfig `yaml:"hubs"`
+}
+
+// LoadConfig reads and validates a multi-hub config file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file %s: %v", path, err)
+	}
+
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %v", path, err)
+	}
+
+	if len(cfg.Hubs) == 0 {
+		return nil, fmt.Errorf("config file %s defines no hubs", path)
+	}
+
+	seen := make(map[string]bool, len(cfg.Hubs))
+	for i := range cfg.Hubs {
+		hub := &cfg.Hubs[i]
+		if hub.Name == "" {
+			return nil, fmt.Errorf("hub %d is missing a name", i)
+		}
+		if seen[hub.Name] {
+			return nil, fmt.Errorf("duplicate hub name %q", hub.Name)
+		}
+		seen[hub.Name] = true
+
+		if hub.OAuthClient == "" || hub.OAuthTokenFile == "" {
+			return nil, fmt.Errorf("hub %q is missing oauth_client or oauth_token_file", hub.Name)
+		}
+
+		if hub.IncludeDevices != "" {
+			re, err := regexp.Compile(hub.IncludeDevices)
+			if err != nil {
+				return nil, fmt.Errorf("hub %q has invalid include_devices regex: %v", hub.Name, err)
+			}
+			hub.includeDevices = re
+		}
+		if hub.ExcludeDevices != "" {
+			re, err := regexp.Compile(hub.ExcludeDevices)
+			if err != nil {
+				return nil, fmt.Errorf("hub %q has invalid exclude_devices regex: %v", hub.Name, err)
+			}
+			hub.excludeDevices = re
+		}
+	}
+
+	return cfg, nil
+}
+
+// Matches reports whether a device name passes this hub's include/exclude filters.
+func (h *HubConfig) Matches(deviceName string) bool {
+	if h.includeDevices != nil && !h.includeDevices.MatchString(deviceName) {
+		return false
+	}
+	if h.excludeDevices != nil && h.excludeDevices.MatchString(deviceName) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestHubConfigMatches(t *testing.T) {
+	tests := []struct {
+		name       string
+		hub        HubConfig
+		deviceName string
+		want       bool
+	}{
+		{name: "no filters matches everything", deviceName: "Kitchen Light", want: true},
+		{
+			name:       "include filter matches",
+			hub:        HubConfig{includeDevices: regexp.MustCompile("^Kitchen")},
+			deviceName: "Kitchen Light",
+			want:       true,
+		},
+		{
+			name:       "include filter excludes non-matching",
+			hub:        HubConfig{includeDevices: regexp.MustCompile("^Kitchen")},
+			deviceName: "Garage Door",
+			want:       false,
+		},
+		{
+			name:       "exclude filter excludes matching",
+			hub:        HubConfig{excludeDevices: regexp.MustCompile("Camera$")},
+			deviceName: "Front Door Camera",
+			want:       false,
+		},
+		{
+			name: "include and exclude combine",
+			hub: HubConfig{
+				includeDevices: regexp.MustCompile("^Front"),
+				excludeDevices: regexp.MustCompile("Camera$"),
+			},
+			deviceName: "Front Door Camera",
+			want:       false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hub.Matches(tt.deviceName); got != tt.want {
+				t.Errorf("Matches(%q) = %v, want %v", tt.deviceName, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,514 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v2"
+)
+
+// Known value mapping types for a MetricEntry.Value.Type.
+const (
+	valueTypeFloat       = "float"
+	valueTypeOneOf       = "one_of"
+	valueTypeClear       = "clear"
+	valueTypeEnum        = "enum"
+	valueTypeEnumState   = "enum_state"
+	valueTypeVector3     = "vector3"
+	valueTypeButtonEvent = "button_event"
+)
+
+// unitConverters maps a `unit` name to the function used to convert a raw
+// float value read from Smartthings into the unit exposed in the metric.
+var unitConverters = map[string]func(float64) float64{
+	"fahrenheit_to_celsius": func(v float64) float64 { return (v - 32) * 5 / 9 },
+	"wh_to_joules":          func(v float64) float64 { return v * 3600000 },
+	"mph_to_mps":            func(v float64) float64 { return v * 0.44704 },
+}
+
+// ValueSpec describes how a raw attribute value is turned into one or more
+// Prometheus series.
+type ValueSpec struct {
+	Type  string             `yaml:"type"`
+	OneOf []string           `yaml:"one_of"`
+	Enum  map[string]float64 `yaml:"enum"`
+
+	// States lists the possible values of an enum_state attribute; each one
+	// becomes a `state` label value on the metric, set to 1 for the
+	// currently observed state and 0 for the rest.
+	States []string `yaml:"states"`
+
+	// Events lists the possible values of a button_event attribute; each
+	// one becomes an `event` label value on the emitted counter.
+	Events []string `yaml:"events"`
+}
+
+// MetricEntry describes how a single Smartthings attribute is mapped to a
+// Prometheus metric, or that it should be dropped.
+type MetricEntry struct {
+	Attribute string    `yaml:"attribute"`
+	Name      string    `yaml:"name"`
+	Help      string    `yaml:"help"`
+	Unit      string    `yaml:"unit"`
+	Value     ValueSpec `yaml:"value"`
+	Drop      bool      `yaml:"drop"`
+}
+
+// MetricsConfig is the document loaded from `--metrics.config`.
+type MetricsConfig struct {
+	Metrics []MetricEntry `yaml:"metrics"`
+}
+
+// LoadMetricsConfig reads and validates a metrics mapping file.
+func LoadMetricsConfig(path string) (*MetricsConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading metrics config file %s: %v", path, err)
+	}
+
+	cfg := &MetricsConfig{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("parsing metrics config file %s: %v", path, err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("metrics config file %s: %v", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Validate reports unknown value types, invalid value definitions and
+// duplicate attribute mappings.
+func (c *MetricsConfig) Validate() error {
+	seen := make(map[string]bool, len(c.Metrics))
+	for _, entry := range c.Metrics {
+		if entry.Attribute == "" {
+			return fmt.Errorf("entry is missing an attribute name")
+		}
+		if seen[entry.Attribute] {
+			return fmt.Errorf("duplicate mapping for attribute %q", entry.Attribute)
+		}
+		seen[entry.Attribute] = true
+
+		if entry.Drop {
+			continue
+		}
+
+		if entry.Name == "" {
+			return fmt.Errorf("attribute %q is missing a metric name", entry.Attribute)
+		}
+
+		switch entry.Value.Type {
+		case valueTypeFloat, valueTypeClear:
+			// No further fields required.
+		case valueTypeOneOf:
+			if len(entry.Value.OneOf) != 2 {
+				return fmt.Errorf("attribute %q: one_of requires exactly 2 values", entry.Attribute)
+			}
+		case valueTypeEnum:
+			if len(entry.Value.Enum) == 0 {
+				return fmt.Errorf("attribute %q: enum requires at least 1 value", entry.Attribute)
+			}
+		case valueTypeEnumState:
+			if len(entry.Value.States) == 0 {
+				return fmt.Errorf("attribute %q: enum_state requires at least 1 state", entry.Attribute)
+			}
+		case valueTypeVector3:
+			// No further fields required.
+		case valueTypeButtonEvent:
+			if len(entry.Value.Events) == 0 {
+				return fmt.Errorf("attribute %q: button_event requires at least 1 event", entry.Attribute)
+			}
+		default:
+			return fmt.Errorf("attribute %q: unknown value type %q", entry.Attribute, entry.Value.Type)
+		}
+
+		if entry.Unit != "" {
+			if entry.Value.Type != valueTypeFloat {
+				return fmt.Errorf("attribute %q: unit is only valid for %q values, got %q", entry.Attribute, valueTypeFloat, entry.Value.Type)
+			}
+			if _, ok := unitConverters[entry.Unit]; !ok {
+				return fmt.Errorf("attribute %q: unknown unit %q", entry.Attribute, entry.Unit)
+			}
+		}
+	}
+	return nil
+}
+
+// defaultMetricsConfig returns the built-in attribute mappings shipped with
+// the exporter, used when `--metrics.config` is not set and as the base that
+// a supplied config file extends/overrides.
+func defaultMetricsConfig() *MetricsConfig {
+	return &MetricsConfig{
+		Metrics: []MetricEntry{
+			{Attribute: "alarm", Name: "alarm", Help: "1 if the alarm is on.",
+				Value: ValueSpec{Type: valueTypeOneOf, OneOf: []string{"off", "on"}}},
+			{Attribute: "alarmState", Name: "alarm_cleared", Help: "0 if the alarm is clear.",
+				Value: ValueSpec{Type: valueTypeClear}},
+			{Attribute: "battery", Name: "battery_percentage", Help: "Percentage of battery remaining.",
+				Value: ValueSpec{Type: valueTypeFloat}},
+			{Attribute: "carbonMonoxide", Name: "contact_closed", Help: "1 if the contact is closed.",
+				Value: ValueSpec{Type: valueTypeClear}},
+			{Attribute: "contact", Name: "contact_closed", Help: "1 if the contact is closed.",
+				Value: ValueSpec{Type: valueTypeOneOf, OneOf: []string{"open", "closed"}}},
+			{Attribute: "energy", Name: "energy_usage_joules", Help: "Energy usage in joules.",
+				Unit: "wh_to_joules", Value: ValueSpec{Type: valueTypeFloat}},
+			{Attribute: "humidity", Name: "humidity_level", Help: "Humidity Level.",
+				Value: ValueSpec{Type: valueTypeFloat}},
+			{Attribute: "fanSpeed", Name: "fan_level", Help: "Fan Level.",
+				Value: ValueSpec{Type: valueTypeFloat}},
+			{Attribute: "illuminance", Name: "lux_level", Help: "LUX Level.",
+				Value: ValueSpec{Type: valueTypeFloat}},
+			{Attribute: "level", Name: "level_percent", Help: "Level.",
+				Value: ValueSpec{Type: valueTypeFloat}},
+			{Attribute: "lock", Name: "locked", Help: "Is Locked.",
+				Value: ValueSpec{Type: valueTypeOneOf, OneOf: []string{"locked", "unlocked"}}},
+			{Attribute: "motion", Name: "motion_detected", Help: "1 if presence is detected.",
+				Value: ValueSpec{Type: valueTypeOneOf, OneOf: []string{"inactive", "active"}}},
+			{Attribute: "power", Name: "power_usage_watts", Help: "Current power usage in watts.",
+				Value: ValueSpec{Type: valueTypeFloat}},
+			{Attribute: "presence", Name: "presence_detected", Help: "1 if presence is detected.",
+				Value: ValueSpec{Type: valueTypeOneOf, OneOf: []string{"not present", "present"}}},
+			{Attribute: "pressure", Name: "pressure_pascals", Help: "Current pressure in pascals.",
+				Value: ValueSpec{Type: valueTypeFloat}},
+			{Attribute: "smoke", Name: "smoke_detected", Help: "1 if smoke is detected.",
+				Value: ValueSpec{Type: valueTypeClear}},
+			{Attribute: "switch", Name: "switch_enabled", Help: "1 if the switch is on.",
+				Value: ValueSpec{Type: valueTypeOneOf, OneOf: []string{"off", "on"}}},
+			{Attribute: "temperature", Name: "temperature_fahrenheit", Help: "Temperature in fahrenheit.",
+				Value: ValueSpec{Type: valueTypeFloat}},
+			{Attribute: "ultravioletIndex", Name: "ultraviolet_index", Help: "Ultraviolet Index.",
+				Value: ValueSpec{Type: valueTypeFloat}},
+
+			// Tesla Stuff
+			{Attribute: "speed", Name: "speed_miles_per_hour", Help: "Speed at Miles Per Hour.",
+				Value: ValueSpec{Type: valueTypeFloat}},
+			{Attribute: "heading", Name: "heading", Help: "heading.",
+				Value: ValueSpec{Type: valueTypeFloat}},
+			{Attribute: "longitude", Name: "longitude", Help: "longitude.",
+				Value: ValueSpec{Type: valueTypeFloat}},
+			{Attribute: "latitude", Name: "latitude", Help: "latitude.",
+				Value: ValueSpec{Type: valueTypeFloat}},
+			{Attribute: "odometer", Name: "odometer", Help: "odometer.",
+				Value: ValueSpec{Type: valueTypeFloat}},
+			{Attribute: "batteryRange", Name: "battery_range", Help: "Range in Miles for Battery.",
+				Value: ValueSpec{Type: valueTypeFloat}},
+
+			// TBD
+			{Attribute: "healthStatus", Name: "healthStatus", Help: "Health Status.",
+				Value: ValueSpec{Type: valueTypeFloat}},
+			{Attribute: "hue", Name: "hue", Help: "Lighting Hue.",
+				Value: ValueSpec{Type: valueTypeFloat}},
+			{Attribute: "saturation", Name: "saturation", Help: "Lighting Saturation.",
+				Value: ValueSpec{Type: valueTypeFloat}},
+			{Attribute: "whiteLevel", Name: "whiteLevel", Help: "White Light Level.",
+				Value: ValueSpec{Type: valueTypeFloat}},
+			{Attribute: "checkInterval", Name: "checkInterval", Help: "Check Interval.",
+				Value: ValueSpec{Type: valueTypeFloat}},
+			{Attribute: "colorTemperature", Name: "colorTemperature", Help: "Color Temperature.",
+				Value: ValueSpec{Type: valueTypeFloat}},
+
+			// Previously dropped for lacking a binary off/on encoding; now
+			// first-class via the enum_state/vector3/button_event mapper
+			// family.
+			{Attribute: "door", Name: "door_state", Help: "Door state, 1 for the current state and 0 for the rest.",
+				Value: ValueSpec{Type: valueTypeEnumState, States: []string{"closed", "open", "tilt", "unknown"}}},
+			{Attribute: "powerSource", Name: "power_source_state", Help: "Power source, 1 for the current source and 0 for the rest.",
+				Value: ValueSpec{Type: valueTypeEnumState, States: []string{"battery", "mains", "dc", "unknown"}}},
+			{Attribute: "acceleration", Name: "acceleration_detected_state", Help: "Acceleration detection state, 1 for the current state and 0 for the rest.",
+				Value: ValueSpec{Type: valueTypeEnumState, States: []string{"inactive", "active"}}},
+			{Attribute: "threeAxis", Name: "acceleration", Help: "3-axis acceleration in g, one series per axis.",
+				Value: ValueSpec{Type: valueTypeVector3}},
+			{Attribute: "button", Name: "button_events_total", Help: "Total number of button events, by event type.",
+				Value: ValueSpec{Type: valueTypeButtonEvent, Events: []string{"pushed", "held", "double"}}},
+
+			{Attribute: "DeviceWatch-DeviceStatus", Drop: true},
+			{Attribute: "DeviceWatch-Enroll", Drop: true},
+			{Attribute: "numberOfButtons", Drop: true},
+			{Attribute: "color", Drop: true},
+			{Attribute: "colorName", Drop: true},
+			{Attribute: "indicatorStatus", Drop: true},
+			{Attribute: "supportedButtonValues", Drop: true},
+			{Attribute: "bulbTemp", Drop: true},
+			{Attribute: "status", Drop: true},
+
+			// Rachio (General)
+			{Attribute: "curZoneIsCycling", Drop: true},
+			{Attribute: "curZoneCycleCount", Drop: true},
+			{Attribute: "controllerOn", Drop: true},
+			{Attribute: "rainDelay", Drop: true},
+			{Attribute: "curZoneNumber", Drop: true},
+			{Attribute: "curZoneWaterTime", Drop: true},
+			{Attribute: "rainDelayStr", Drop: true},
+			{Attribute: "hardwareModel", Drop: true},
+			{Attribute: "hardwareDesc", Drop: true},
+			{Attribute: "activeZoneCnt", Drop: true},
+			{Attribute: "curZoneRunStatus", Drop: true},
+			{Attribute: "standbyMode", Drop: true},
+			{Attribute: "curZoneName", Drop: true},
+			{Attribute: "curZoneDuration", Drop: true},
+			{Attribute: "curZoneStartDate", Drop: true},
+
+			// Rachio (Valves)
+			{Attribute: "zoneSquareFeet", Drop: true},
+			{Attribute: "efficiency", Drop: true},
+			{Attribute: "indicashadeNametorStatus", Drop: true},
+			{Attribute: "zoneName", Drop: true},
+			{Attribute: "saturatedDepthOfWater", Drop: true},
+			{Attribute: "zoneNumber", Drop: true},
+			{Attribute: "watering", Drop: true},
+			{Attribute: "zoneTotalDuration", Drop: true},
+			{Attribute: "rootZoneDepth", Drop: true},
+			{Attribute: "zoneWaterTime", Drop: true},
+			{Attribute: "depthOfWater", Drop: true},
+			{Attribute: "zoneElapsed", Drop: true},
+			{Attribute: "slopeName", Drop: true},
+			{Attribute: "cropName", Drop: true},
+			{Attribute: "availableWater", Drop: true},
+			{Attribute: "nozzleName", Drop: true},
+			{Attribute: "maxRuntime", Drop: true},
+			{Attribute: "zoneDuration", Drop: true},
+			{Attribute: "zoneStartDate", Drop: true},
+			{Attribute: "zoneCycleCount", Drop: true},
+			{Attribute: "inStandby", Drop: true},
+			{Attribute: "lastUpdatedDt", Drop: true},
+			{Attribute: "scheduleType", Drop: true},
+			{Attribute: "shadeName", Drop: true},
+			{Attribute: "valve", Drop: true},
+			{Attribute: "soilName", Drop: true},
+
+			// DLINK Cam Stuff
+			{Attribute: "image", Drop: true},
+			{Attribute: "statusMessage", Drop: true},
+			{Attribute: "mute", Drop: true},
+			{Attribute: "hubactionMode", Drop: true},
+			{Attribute: "switch2", Drop: true},
+			{Attribute: "switch3", Drop: true},
+			{Attribute: "switch4", Drop: true},
+			{Attribute: "switch5", Drop: true},
+			{Attribute: "switch6", Drop: true},
+			{Attribute: "captureTime", Drop: true},
+			{Attribute: "camera", Drop: true},
+			{Attribute: "settings", Drop: true},
+			{Attribute: "stream", Drop: true},
+			{Attribute: "clip", Drop: true},
+
+			// Arlo Cams Stuff
+			{Attribute: "nightVision", Drop: true},
+			{Attribute: "powerManagement", Drop: true},
+			{Attribute: "desiredCameraState", Drop: true},
+			{Attribute: "ruleId", Drop: true},
+			{Attribute: "sound", Drop: true},
+			{Attribute: "invertImage", Drop: true},
+			{Attribute: "offline", Drop: true},
+			{Attribute: "rssi", Drop: true},
+			{Attribute: "active", Drop: true},
+			{Attribute: "timeLastRefresh", Drop: true},
+			{Attribute: "lqi", Drop: true},
+			{Attribute: "clipStatus", Drop: true},
+
+			// Room Stuff
+			{Attribute: "occupancy", Drop: true},
+			{Attribute: "occupancyIconURL", Drop: true},
+			{Attribute: "countdown", Drop: true},
+
+			// Multisensor Stuff
+			{Attribute: "batteryStatus", Drop: true},
+			{Attribute: "tamper", Drop: true},
+		},
+	}
+}
+
+// mergeMetricsConfig overlays override entries onto the base config,
+// replacing any base entry with the same attribute name and appending new
+// ones, so a supplied `--metrics.config` only needs to declare what it adds
+// or changes.
+func mergeMetricsConfig(base, override *MetricsConfig) *MetricsConfig {
+	merged := &MetricsConfig{Metrics: make([]MetricEntry, 0, len(base.Metrics)+len(override.Metrics))}
+	index := make(map[string]int, len(base.Metrics))
+	for _, entry := range base.Metrics {
+		index[entry.Attribute] = len(merged.Metrics)
+		merged.Metrics = append(merged.Metrics, entry)
+	}
+	for _, entry := range override.Metrics {
+		if i, ok := index[entry.Attribute]; ok {
+			merged.Metrics[i] = entry
+			continue
+		}
+		index[entry.Attribute] = len(merged.Metrics)
+		merged.Metrics = append(merged.Metrics, entry)
+	}
+	return merged
+}
+
+// metric describes a single mapped Smartthings attribute: its metric name
+// and help text (shared by every output mode), a Prometheus descriptor built
+// from them, and the function that turns a raw attribute value into the
+// float64 to record.
+//
+// multi is set instead of description/valueMapper for attributes that don't
+// reduce to a single scalar series, such as enum states, 3-axis vectors and
+// button events; collectDevice dispatches to it directly.
+type metric struct {
+	name        string
+	help        string
+	description *prometheus.Desc
+	valueMapper func(interface{}) (float64, error)
+	multi       multiMetric
+}
+
+// metricsRegistry is the compiled result of a MetricsConfig: a metric
+// definition per mapped attribute, plus the set of attributes to silently
+// drop.
+type metricsRegistry struct {
+	metrics       map[string]*metric
+	metricsToDrop map[string]bool
+}
+
+// buildMetricsRegistry compiles a validated MetricsConfig into Prometheus
+// descriptors and value mappers.
+func buildMetricsRegistry(cfg *MetricsConfig) (*metricsRegistry, error) {
+	reg := &metricsRegistry{
+		metrics:       make(map[string]*metric, len(cfg.Metrics)),
+		metricsToDrop: make(map[string]bool),
+	}
+
+	for _, entry := range cfg.Metrics {
+		if entry.Drop {
+			reg.metricsToDrop[entry.Attribute] = true
+			continue
+		}
+
+		switch entry.Value.Type {
+		case valueTypeEnumState, valueTypeVector3, valueTypeButtonEvent:
+			m, err := multiMetricFor(entry)
+			if err != nil {
+				return nil, fmt.Errorf("attribute %q: %v", entry.Attribute, err)
+			}
+			reg.metrics[entry.Attribute] = &metric{name: entry.Name, help: entry.Help, multi: m}
+			continue
+		}
+
+		mapper, err := valueMapperFor(entry.Value)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %q: %v", entry.Attribute, err)
+		}
+		if convert, ok := unitConverters[entry.Unit]; ok {
+			inner := mapper
+			mapper = func(v interface{}) (float64, error) {
+				value, err := inner(v)
+				if err != nil {
+					return 0, err
+				}
+				return convert(value), nil
+			}
+		}
+
+		reg.metrics[entry.Attribute] = &metric{
+			name: entry.Name,
+			help: entry.Help,
+			description: prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, "", entry.Name), entry.Help, deviceLabels, nil),
+			valueMapper: mapper,
+		}
+	}
+
+	return reg, nil
+}
+
+// valueMapperFor returns the value mapping function described by spec.
+func valueMapperFor(spec ValueSpec) (func(interface{}) (float64, error), error) {
+	switch spec.Type {
+	case valueTypeFloat:
+		return valueFloat, nil
+	case valueTypeClear:
+		return valueClear, nil
+	case valueTypeOneOf:
+		options := spec.OneOf
+		return func(i interface{}) (float64, error) {
+			return valueOneOf(i, options)
+		}, nil
+	case valueTypeEnum:
+		table := spec.Enum
+		return func(i interface{}) (float64, error) {
+			return valueLookup(i, table)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown value type %q", spec.Type)
+	}
+}
+
+// valueClear expects a string and returns 0 for "clear", 1 for anything else.
+// TODO: Expand this to properly identify non-clear conditions and return error
+// in case an unexpected value is found.
+func valueClear(v interface{}) (float64, error) {
+	val, ok := v.(string)
+	if !ok {
+		return 0.0, fmt.Errorf("invalid non-string argument %v", v)
+	}
+	if val == "clear" {
+		return 0.0, nil
+	}
+	return 1.0, nil
+}
+
+// valueOneOf returns 0.0 if the value matches the first item
+// in the array, 1.0 if it matches the second, and an error if
+// nothing matches.
+func valueOneOf(v interface{}, options []string) (float64, error) {
+	val, ok := v.(string)
+	if !ok {
+		return 0.0, fmt.Errorf("invalid non-string argument %v", v)
+	}
+	if val == options[0] {
+		return 0.0, nil
+	}
+	if val == options[1] {
+		return 1.0, nil
+	}
+	return 0.0, fmt.Errorf("invalid option %q. Expected %q or %q", val, options[0], options[1])
+}
+
+// valueFloat returns the float64 value of the value passed or
+// error if the value cannot be converted.
+func valueFloat(v interface{}) (float64, error) {
+	stringVal, ok := v.(string)
+	if ok && stringVal == "" {
+		return 0.0, nil
+	}
+	val, ok := v.(float64)
+	if !ok {
+		return 0.0, fmt.Errorf("invalid non floating-point argument %v", v)
+	}
+	return val, nil
+}
+
+// valueLookup returns the float64 that table associates with the string
+// value passed, or an error if the value isn't a key in table.
+func valueLookup(v interface{}, table map[string]float64) (float64, error) {
+	val, ok := v.(string)
+	if !ok {
+		return 0.0, fmt.Errorf("invalid non-string argument %v", v)
+	}
+	f, ok := table[val]
+	if !ok {
+		return 0.0, fmt.Errorf("invalid value %q for enum mapping", val)
+	}
+	return f, nil
+}
@@ -0,0 +1,166 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import "testing"
+
+func TestMetricsConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     MetricsConfig
+		wantErr bool
+	}{
+		{
+			name: "valid float with unit",
+			cfg: MetricsConfig{Metrics: []MetricEntry{
+				{Attribute: "energy", Name: "energy_usage_joules", Unit: "wh_to_joules",
+					Value: ValueSpec{Type: valueTypeFloat}},
+			}},
+		},
+		{
+			name: "unit on non-float entry is rejected",
+			cfg: MetricsConfig{Metrics: []MetricEntry{
+				{Attribute: "switch", Name: "switch_enabled", Unit: "fahrenheit_to_celsius",
+					Value: ValueSpec{Type: valueTypeOneOf, OneOf: []string{"off", "on"}}},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "unknown unit",
+			cfg: MetricsConfig{Metrics: []MetricEntry{
+				{Attribute: "temperature", Name: "temperature", Unit: "kelvin_to_rankine",
+					Value: ValueSpec{Type: valueTypeFloat}},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "missing attribute name",
+			cfg: MetricsConfig{Metrics: []MetricEntry{
+				{Name: "foo", Value: ValueSpec{Type: valueTypeFloat}},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "duplicate attribute",
+			cfg: MetricsConfig{Metrics: []MetricEntry{
+				{Attribute: "battery", Name: "a", Value: ValueSpec{Type: valueTypeFloat}},
+				{Attribute: "battery", Name: "b", Value: ValueSpec{Type: valueTypeFloat}},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "drop entries skip name/value checks",
+			cfg: MetricsConfig{Metrics: []MetricEntry{
+				{Attribute: "image", Drop: true},
+			}},
+		},
+		{
+			name: "one_of requires exactly 2 values",
+			cfg: MetricsConfig{Metrics: []MetricEntry{
+				{Attribute: "lock", Name: "locked", Value: ValueSpec{Type: valueTypeOneOf, OneOf: []string{"locked"}}},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "unknown value type",
+			cfg: MetricsConfig{Metrics: []MetricEntry{
+				{Attribute: "foo", Name: "foo", Value: ValueSpec{Type: "bogus"}},
+			}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMergeMetricsConfig(t *testing.T) {
+	base := &MetricsConfig{Metrics: []MetricEntry{
+		{Attribute: "battery", Name: "battery_percentage", Value: ValueSpec{Type: valueTypeFloat}},
+		{Attribute: "switch", Name: "switch_enabled", Value: ValueSpec{Type: valueTypeOneOf, OneOf: []string{"off", "on"}}},
+	}}
+	override := &MetricsConfig{Metrics: []MetricEntry{
+		{Attribute: "battery", Name: "battery_pct", Value: ValueSpec{Type: valueTypeFloat}},
+		{Attribute: "newAttr", Name: "new_attr", Value: ValueSpec{Type: valueTypeFloat}},
+	}}
+
+	merged := mergeMetricsConfig(base, override)
+	if len(merged.Metrics) != 3 {
+		t.Fatalf("expected 3 merged entries, got %d", len(merged.Metrics))
+	}
+
+	byAttr := make(map[string]MetricEntry, len(merged.Metrics))
+	for _, e := range merged.Metrics {
+		byAttr[e.Attribute] = e
+	}
+
+	if got := byAttr["battery"].Name; got != "battery_pct" {
+		t.Errorf("override did not replace base entry: got name %q", got)
+	}
+	if got := byAttr["switch"].Name; got != "switch_enabled" {
+		t.Errorf("base-only entry was not preserved: got name %q", got)
+	}
+	if _, ok := byAttr["newAttr"]; !ok {
+		t.Errorf("override-only entry was not appended")
+	}
+}
+
+func TestValueMapperFor(t *testing.T) {
+	floatMapper, err := valueMapperFor(ValueSpec{Type: valueTypeFloat})
+	if err != nil {
+		t.Fatalf("valueMapperFor(float) error: %v", err)
+	}
+	if v, err := floatMapper(12.5); err != nil || v != 12.5 {
+		t.Errorf("floatMapper(12.5) = %v, %v, want 12.5, nil", v, err)
+	}
+
+	enumMapper, err := valueMapperFor(ValueSpec{Type: valueTypeEnum, Enum: map[string]float64{"low": 0, "high": 1}})
+	if err != nil {
+		t.Fatalf("valueMapperFor(enum) error: %v", err)
+	}
+	if v, err := enumMapper("high"); err != nil || v != 1 {
+		t.Errorf("enumMapper(\"high\") = %v, %v, want 1, nil", v, err)
+	}
+	if _, err := enumMapper("medium"); err == nil {
+		t.Errorf("enumMapper(\"medium\") expected error, got nil")
+	}
+
+	if _, err := valueMapperFor(ValueSpec{Type: "bogus"}); err == nil {
+		t.Errorf("valueMapperFor(bogus) expected error, got nil")
+	}
+}
+
+func TestValueLookup(t *testing.T) {
+	table := map[string]float64{"low": 0, "high": 1}
+
+	v, err := valueLookup("high", table)
+	if err != nil || v != 1 {
+		t.Errorf("valueLookup(\"high\") = %v, %v, want 1, nil", v, err)
+	}
+
+	if _, err := valueLookup("unknown", table); err == nil {
+		t.Errorf("valueLookup(\"unknown\") expected error, got nil")
+	}
+
+	if _, err := valueLookup(42.0, table); err == nil {
+		t.Errorf("valueLookup(42.0) expected error for non-string argument, got nil")
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/seanmf83/gosmart"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// multiMetric is implemented by attribute mappings that emit more than one
+// Prometheus series per device, instead of the single float64 produced by a
+// scalar valueMapper.
+type multiMetric interface {
+	describe(ch chan<- *prometheus.Desc)
+	emit(ch chan<- prometheus.Metric, otlp *otlpSink, dev gosmart.DeviceInfo, hub string, raw interface{}) error
+}
+
+// multiMetricFor returns the multiMetric implementation for entry's value
+// type.
+func multiMetricFor(entry MetricEntry) (multiMetric, error) {
+	switch entry.Value.Type {
+	case valueTypeEnumState:
+		return newEnumStateMetric(entry), nil
+	case valueTypeVector3:
+		return newVector3Metric(entry), nil
+	case valueTypeButtonEvent:
+		return newButtonEventMetric(entry), nil
+	default:
+		return nil, fmt.Errorf("unknown multi-value type %q", entry.Value.Type)
+	}
+}
+
+// enumStateMetric exposes an arbitrary enumeration as a Prometheus "state
+// set": one series per possible state, set to 1 for the currently observed
+// state and 0 for the rest.
+type enumStateMetric struct {
+	name   string
+	help   string
+	states []string
+	desc   *prometheus.Desc
+}
+
+func newEnumStateMetric(entry MetricEntry) *enumStateMetric {
+	return &enumStateMetric{
+		name:   entry.Name,
+		help:   entry.Help,
+		states: entry.Value.States,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", entry.Name), entry.Help,
+			append(append([]string{}, deviceLabels...), "state"), nil),
+	}
+}
+
+func (m *enumStateMetric) describe(ch chan<- *prometheus.Desc) {
+	ch <- m.desc
+}
+
+func (m *enumStateMetric) emit(ch chan<- prometheus.Metric, otlp *otlpSink, dev gosmart.DeviceInfo, hub string, raw interface{}) error {
+	val, ok := raw.(string)
+	if !ok {
+		return fmt.Errorf("invalid non-string argument %v", raw)
+	}
+
+	found := false
+	for _, state := range m.states {
+		if state == val {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("invalid state %q, expected one of %v", val, m.states)
+	}
+
+	for _, state := range m.states {
+		value := 0.0
+		if state == val {
+			value = 1.0
+		}
+		if ch != nil {
+			ch <- prometheus.MustNewConstMetric(m.desc, prometheus.GaugeValue, value, dev.ID, dev.DisplayName, hub, state)
+		}
+		if otlp != nil {
+			otlp.observe(m.name, m.help, value, dev, hub, attribute.String("state", state))
+		}
+	}
+	return nil
+}
+
+// vector3 is a parsed threeAxis {"x":..,"y":..,"z":..} attribute.
+type vector3 struct {
+	x, y, z float64
+}
+
+// parseVector3 accepts either a JSON-encoded threeAxis string or an
+// already-decoded map, as returned by gosmart for JSON-valued attributes.
+func parseVector3(raw interface{}) (vector3, error) {
+	var data map[string]interface{}
+	switch v := raw.(type) {
+	case string:
+		if err := json.Unmarshal([]byte(v), &data); err != nil {
+			return vector3{}, fmt.Errorf("invalid threeAxis value %q: %v", v, err)
+		}
+	case map[string]interface{}:
+		data = v
+	default:
+		return vector3{}, fmt.Errorf("invalid non-object argument %v", raw)
+	}
+
+	x, xok := data["x"].(float64)
+	y, yok := data["y"].(float64)
+	z, zok := data["z"].(float64)
+	if !xok || !yok || !zok {
+		return vector3{}, fmt.Errorf("threeAxis value missing x/y/z fields: %v", raw)
+	}
+	return vector3{x: x, y: y, z: z}, nil
+}
+
+// vector3Metric exposes a threeAxis JSON attribute as three gauges, one per
+// axis.
+type vector3Metric struct {
+	name  string
+	help  string
+	descX *prometheus.Desc
+	descY *prometheus.Desc
+	descZ *prometheus.Desc
+}
+
+func newVector3Metric(entry MetricEntry) *vector3Metric {
+	return &vector3Metric{
+		name:  entry.Name,
+		help:  entry.Help,
+		descX: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", entry.Name+"_x_g"), entry.Help+" (x axis).", deviceLabels, nil),
+		descY: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", entry.Name+"_y_g"), entry.Help+" (y axis).", deviceLabels, nil),
+		descZ: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", entry.Name+"_z_g"), entry.Help+" (z axis).", deviceLabels, nil),
+	}
+}
+
+func (m *vector3Metric) describe(ch chan<- *prometheus.Desc) {
+	ch <- m.descX
+	ch <- m.descY
+	ch <- m.descZ
+}
+
+func (m *vector3Metric) emit(ch chan<- prometheus.Metric, otlp *otlpSink, dev gosmart.DeviceInfo, hub string, raw interface{}) error {
+	axes, err := parseVector3(raw)
+	if err != nil {
+		return err
+	}
+
+	for _, axis := range []struct {
+		desc  *prometheus.Desc
+		name  string
+		value float64
+	}{
+		{m.descX, m.name + "_x_g", axes.x},
+		{m.descY, m.name + "_y_g", axes.y},
+		{m.descZ, m.name + "_z_g", axes.z},
+	} {
+		if ch != nil {
+			ch <- prometheus.MustNewConstMetric(axis.desc, prometheus.GaugeValue, axis.value, dev.ID, dev.DisplayName, hub)
+		}
+		if otlp != nil {
+			otlp.observe(axis.name, m.help, axis.value, dev, hub)
+		}
+	}
+	return nil
+}
+
+// buttonEventMetric exposes a button attribute as a counter of observed
+// events, incremented each time a device's value transitions to a new
+// event, labeled by the event name.
+type buttonEventMetric struct {
+	name   string
+	help   string
+	events []string
+	desc   *prometheus.Desc
+
+	mu     sync.Mutex
+	last   map[string]string
+	totals map[string]map[string]float64 // device id -> event -> cumulative count
+}
+
+func newButtonEventMetric(entry MetricEntry) *buttonEventMetric {
+	return &buttonEventMetric{
+		name:   entry.Name,
+		help:   entry.Help,
+		events: entry.Value.Events,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", entry.Name), entry.Help,
+			append(append([]string{}, deviceLabels...), "event"), nil),
+		last:   make(map[string]string),
+		totals: make(map[string]map[string]float64),
+	}
+}
+
+func (m *buttonEventMetric) describe(ch chan<- *prometheus.Desc) {
+	ch <- m.desc
+}
+
+func (m *buttonEventMetric) emit(ch chan<- prometheus.Metric, otlp *otlpSink, dev gosmart.DeviceInfo, hub string, raw interface{}) error {
+	val, ok := raw.(string)
+	if !ok {
+		return fmt.Errorf("invalid non-string argument %v", raw)
+	}
+
+	found := false
+	for _, event := range m.events {
+		if event == val {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("invalid event %q, expected one of %v", val, m.events)
+	}
+
+	m.mu.Lock()
+	if m.totals[dev.ID] == nil {
+		m.totals[dev.ID] = make(map[string]float64)
+	}
+	if m.last[dev.ID] != val {
+		m.last[dev.ID] = val
+		m.totals[dev.ID][val]++
+	}
+	totals := make(map[string]float64, len(m.events))
+	for event, total := range m.totals[dev.ID] {
+		totals[event] = total
+	}
+	m.mu.Unlock()
+
+	for _, event := range m.events {
+		total := totals[event]
+		if ch != nil {
+			ch <- prometheus.MustNewConstMetric(m.desc, prometheus.CounterValue, total, dev.ID, dev.DisplayName, hub, event)
+		}
+		if otlp != nil {
+			otlp.observeCounter(m.name, m.help, total, dev, hub, attribute.String("event", event))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/seanmf83/gosmart"
+)
+
+func TestParseVector3(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     interface{}
+		want    vector3
+		wantErr bool
+	}{
+		{
+			name: "JSON-encoded string",
+			raw:  `{"x":1.5,"y":-2,"z":0}`,
+			want: vector3{x: 1.5, y: -2, z: 0},
+		},
+		{
+			name: "already-decoded map",
+			raw:  map[string]interface{}{"x": 1.0, "y": 2.0, "z": 3.0},
+			want: vector3{x: 1, y: 2, z: 3},
+		},
+		{
+			name:    "invalid JSON",
+			raw:     `not json`,
+			wantErr: true,
+		},
+		{
+			name:    "missing field",
+			raw:     map[string]interface{}{"x": 1.0, "y": 2.0},
+			wantErr: true,
+		},
+		{
+			name:    "non-object argument",
+			raw:     42.0,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseVector3(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseVector3() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("parseVector3() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestButtonEventMetricEmit(t *testing.T) {
+	m := newButtonEventMetric(MetricEntry{
+		Name: "button_events_total", Help: "Total number of button events.",
+		Value: ValueSpec{Type: valueTypeButtonEvent, Events: []string{"pushed", "held"}},
+	})
+	dev := gosmart.DeviceInfo{DeviceList: gosmart.DeviceList{ID: "dev1", DisplayName: "Button"}}
+
+	if err := m.emit(nil, nil, dev, "hub1", "pushed"); err != nil {
+		t.Fatalf("emit(pushed) error: %v", err)
+	}
+	if err := m.emit(nil, nil, dev, "hub1", "pushed"); err != nil {
+		t.Fatalf("emit(pushed again) error: %v", err)
+	}
+	if got := m.totals["dev1"]["pushed"]; got != 1 {
+		t.Errorf("repeated identical value should not double-count: totals[pushed] = %v, want 1", got)
+	}
+
+	if err := m.emit(nil, nil, dev, "hub1", "held"); err != nil {
+		t.Fatalf("emit(held) error: %v", err)
+	}
+	if got := m.totals["dev1"]["held"]; got != 1 {
+		t.Errorf("totals[held] = %v, want 1", got)
+	}
+
+	if err := m.emit(nil, nil, dev, "hub1", "pushed"); err != nil {
+		t.Fatalf("emit(pushed after transition) error: %v", err)
+	}
+	if got := m.totals["dev1"]["pushed"]; got != 2 {
+		t.Errorf("transitioning back to pushed should increment it again: totals[pushed] = %v, want 2", got)
+	}
+
+	if err := m.emit(nil, nil, dev, "hub1", "unknown"); err == nil {
+		t.Errorf("emit(unknown event) expected error, got nil")
+	}
+}
+
+func TestEnumStateMetricEmit(t *testing.T) {
+	m := newEnumStateMetric(MetricEntry{
+		Name: "door_state", Help: "Door state.",
+		Value: ValueSpec{Type: valueTypeEnumState, States: []string{"closed", "open"}},
+	})
+	dev := gosmart.DeviceInfo{DeviceList: gosmart.DeviceList{ID: "dev1", DisplayName: "Door"}}
+
+	if err := m.emit(nil, nil, dev, "hub1", "open"); err != nil {
+		t.Fatalf("emit(open) error: %v", err)
+	}
+	if err := m.emit(nil, nil, dev, "hub1", "unknown"); err == nil {
+		t.Errorf("emit(unknown state) expected error, got nil")
+	}
+	if err := m.emit(nil, nil, dev, "hub1", 42.0); err == nil {
+		t.Errorf("emit(non-string) expected error, got nil")
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	plog "github.com/prometheus/common/log"
+	"github.com/seanmf83/gosmart"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+// Supported values for --output.mode.
+const (
+	outputModePrometheus = "prometheus"
+	outputModeOTLP       = "otlp"
+	outputModeBoth       = "both"
+)
+
+// Supported values for --otlp.protocol.
+const (
+	otlpProtocolGRPC = "grpc"
+	otlpProtocolHTTP = "http"
+)
+
+const meterName = "github.com/seanmf83/smartthings_exporter"
+
+// newOTLPMeterProvider builds a periodic-export metric pipeline that pushes
+// to the collector at endpoint every interval, using protocol and headers
+// from the matching --otlp.* flags.
+func newOTLPMeterProvider(endpoint, protocol, headers string, interval time.Duration) (*sdkmetric.MeterProvider, error) {
+	parsedHeaders, err := parseOTLPHeaders(headers)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	var exporter sdkmetric.Exporter
+	switch protocol {
+	case otlpProtocolGRPC:
+		exporter, err = otlpmetricgrpc.New(ctx,
+			otlpmetricgrpc.WithEndpoint(endpoint),
+			otlpmetricgrpc.WithHeaders(parsedHeaders),
+			otlpmetricgrpc.WithInsecure(),
+		)
+	case otlpProtocolHTTP:
+		exporter, err = otlpmetrichttp.New(ctx,
+			otlpmetrichttp.WithEndpoint(endpoint),
+			otlpmetrichttp.WithHeaders(parsedHeaders),
+			otlpmetrichttp.WithInsecure(),
+		)
+	default:
+		return nil, fmt.Errorf("unknown --otlp.protocol %q, expected %q or %q", protocol, otlpProtocolGRPC, otlpProtocolHTTP)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP exporter: %v", err)
+	}
+
+	res, err := resource.Merge(resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName("smartthings_exporter")))
+	if err != nil {
+		return nil, fmt.Errorf("building OTLP resource: %v", err)
+	}
+
+	return sdkmetric.NewMeterProvider(
+		sdkmetric.WithResource(res),
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(interval))),
+	), nil
+}
+
+// parseOTLPHeaders parses the comma-separated `key=value` pairs accepted by
+// --otlp.headers into the map expected by the OTLP exporters.
+func parseOTLPHeaders(raw string) (map[string]string, error) {
+	headers := make(map[string]string)
+	if raw == "" {
+		return headers, nil
+	}
+	for _, pair := range strings.Split(raw, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid --otlp.headers entry %q, expected key=value", pair)
+		}
+		headers[kv[0]] = kv[1]
+	}
+	return headers, nil
+}
+
+// otlpSample is the most recently observed value for one device's attribute,
+// along with the resource attributes it should be reported with.
+type otlpSample struct {
+	value float64
+	attrs attribute.Set
+}
+
+// otlpSink adapts collected device metric values to OpenTelemetry async
+// instruments, one per Smartthings attribute name, so the same Collect path
+// used for Prometheus can also feed an OTLP pipeline. Gauge-backed names use
+// an async gauge whose callback reports the latest sample per device
+// (mirroring Prometheus gauge semantics); counter-backed names (see
+// observeCounter) use an async counter instead, matching the CounterValue
+// used on the Prometheus side for the same metric.
+type otlpSink struct {
+	meter otelmetric.Meter
+
+	mu       sync.Mutex
+	gauges   map[string]otelmetric.Float64ObservableGauge
+	counters map[string]otelmetric.Float64ObservableCounter
+	samples  map[string]map[string]otlpSample // metric name -> sample key -> sample
+}
+
+// newOTLPSink returns an otlpSink that registers its instruments against meter.
+func newOTLPSink(meter otelmetric.Meter) *otlpSink {
+	return &otlpSink{
+		meter:    meter,
+		gauges:   make(map[string]otelmetric.Float64ObservableGauge),
+		counters: make(map[string]otelmetric.Float64ObservableCounter),
+		samples:  make(map[string]map[string]otlpSample),
+	}
+}
+
+// observe records dev's current value for the gauge named name (lazily
+// creating it the first time this name is seen), labeled with id, name and
+// hub plus any extra attributes the caller supplies (e.g. a `state` label
+// for multi-series metrics).
+func (s *otlpSink) observe(name, help string, value float64, dev gosmart.DeviceInfo, hub string, extra ...attribute.KeyValue) {
+	s.record(false, name, help, value, dev, hub, extra...)
+}
+
+// observeCounter is like observe but backs name with an async counter
+// instrument instead of a gauge, for cumulative values such as
+// smartthings_button_events_total.
+func (s *otlpSink) observeCounter(name, help string, value float64, dev gosmart.DeviceInfo, hub string, extra ...attribute.KeyValue) {
+	s.record(true, name, help, value, dev, hub, extra...)
+}
+
+func (s *otlpSink) record(counter bool, name, help string, value float64, dev gosmart.DeviceInfo, hub string, extra ...attribute.KeyValue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.samples[name]; !ok {
+		if counter {
+			inst, err := s.meter.Float64ObservableCounter(name,
+				otelmetric.WithDescription(help),
+				otelmetric.WithFloat64Callback(s.callback(name)),
+			)
+			if err != nil {
+				plog.Errorf("Failed to create OTLP counter %s: %v\n", name, err)
+				return
+			}
+			s.counters[name] = inst
+		} else {
+			inst, err := s.meter.Float64ObservableGauge(name,
+				otelmetric.WithDescription(help),
+				otelmetric.WithFloat64Callback(s.callback(name)),
+			)
+			if err != nil {
+				plog.Errorf("Failed to create OTLP gauge %s: %v\n", name, err)
+				return
+			}
+			s.gauges[name] = inst
+		}
+		s.samples[name] = make(map[string]otlpSample)
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.String("id", dev.ID),
+		attribute.String("name", dev.DisplayName),
+	}
+	if hub != "" {
+		attrs = append(attrs, attribute.String("hub", hub))
+	}
+	attrs = append(attrs, extra...)
+
+	sampleKey := dev.ID
+	for _, kv := range extra {
+		sampleKey += "|" + string(kv.Key) + "=" + kv.Value.Emit()
+	}
+
+	s.samples[name][sampleKey] = otlpSample{
+		value: value,
+		attrs: attribute.NewSet(attrs...),
+	}
+}
+
+// callback returns the Float64Callback registered for the instrument named
+// name. It reports the latest sample recorded for every device (and extra
+// label combination) seen so far.
+func (s *otlpSink) callback(name string) otelmetric.Float64Callback {
+	return func(_ context.Context, o otelmetric.Float64Observer) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, sample := range s.samples[name] {
+			o.Observe(sample.value, otelmetric.WithAttributeSet(sample.attrs))
+		}
+		return nil
+	}
+}
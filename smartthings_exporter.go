@@ -23,7 +23,11 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -37,6 +41,9 @@ import (
 
 const (
 	namespace = "smartthings"
+
+	defaultScrapeConcurrency = 4
+	defaultScrapeTimeout     = 10 * time.Second
 )
 
 var (
@@ -48,18 +55,27 @@ var (
 	registerOAuthSecret    *string
 	registerOAuthTokenFile **os.File
 
-	monitorCommand        *kingpin.CmdClause
-	listenAddress         *string
-	metricsPath           *string
-	monitorOAuthClient    *string
-	monitorOAuthTokenFile *string
-
-	valOpenClosed     = []string{"open", "closed"}
-	valLockedUnlocked = []string{"locked", "unlocked"}
-	valInactiveActive = []string{"inactive", "active"}
-	valAbsentPresent  = []string{"not present", "present"}
-	valOffOn          = []string{"off", "on"}
-	invalidMetric     = prometheus.NewCounter(
+	monitorCommand           *kingpin.CmdClause
+	listenAddress            *string
+	metricsPath              *string
+	monitorOAuthClient       *string
+	monitorOAuthTokenFile    *string
+	monitorConfigFile        *string
+	monitorMetricsConfigFile *string
+	monitorScrapeConcurrency *int
+	monitorScrapeTimeout     *time.Duration
+
+	monitorOutputMode   *string
+	monitorOTLPEndpoint *string
+	monitorOTLPHeaders  *string
+	monitorOTLPProtocol *string
+	monitorOTLPInterval *time.Duration
+
+	checkMetricsConfigCommand *kingpin.CmdClause
+	checkMetricsConfigFile    *string
+
+	deviceLabels  = []string{"id", "name", "hub"}
+	invalidMetric = prometheus.NewCounter(
 		prometheus.CounterOpts{
 			Name: "smartthings_invalid_metric",
 			Help: "Total number of metrics that were invalid.",
@@ -77,276 +93,96 @@ var (
 			Help: "Total number of metrics that exporter purposely dropped.",
 		},
 	)
-	metricsToDrop = map[string]string{
-		"DeviceWatch-DeviceStatus": "stuff here",
-		"DeviceWatch-Enroll":       "stuff here",
-		"numberOfButtons":          "stuff here",
-		"color":                    "stuff here",
-		"colorName":                "stuff here",
-		"button":                   "stuff here",
-		"indicatorStatus":          "stuff here",
-
-		"supportedButtonValues": "stuff here",
-		"bulbTemp":              "stuff here",
-
-		"status":       "stuff here",
-		"threeAxis":    "stuff here",
-		"acceleration": "stuff here",
-		"door":         "stuff here",
-
-		// Rachio (General)
-		"curZoneIsCycling":  "stuff here",
-		"curZoneCycleCount": "stuff here",
-		"controllerOn":      "stuff here",
-		"rainDelay":         "stuff here",
-		"curZoneNumber":     "stuff here",
-		"curZoneWaterTime":  "stuff here",
-		"rainDelayStr":      "stuff here",
-		"hardwareModel":     "stuff here",
-		"hardwareDesc":      "stuff here",
-		"activeZoneCnt":     "stuff here",
-		"curZoneRunStatus":  "stuff here",
-		"standbyMode":       "stuff here",
-		"curZoneName":       "stuff here",
-		"curZoneDuration":   "stuff here",
-		"curZoneStartDate":  "stuff here",
-
-		// Rachio (Valves)
-		"zoneSquareFeet":           "stuff here",
-		"efficiency":               "stuff here",
-		"indicashadeNametorStatus": "stuff here",
-		"zoneName":                 "stuff here",
-		"saturatedDepthOfWater":    "stuff here",
-		"zoneNumber":               "stuff here",
-		"watering":                 "stuff here",
-		"zoneTotalDuration":        "stuff here",
-		"rootZoneDepth":            "stuff here",
-		"zoneWaterTime":            "stuff here",
-		"depthOfWater":             "stuff here",
-		"zoneElapsed":              "stuff here",
-		"slopeName":                "stuff here",
-		"cropName":                 "stuff here",
-		"availableWater":           "stuff here",
-		"nozzleName":               "stuff here",
-		"maxRuntime":               "stuff here",
-		"zoneDuration":             "stuff here",
-		"zoneStartDate":            "stuff here",
-		"zoneCycleCount":           "stuff here",
-		"inStandby":                "stuff here",
-		"lastUpdatedDt":            "stuff here",
-		"scheduleType":             "stuff here",
-		"shadeName":                "stuff here",
-		"valve":                    "stuff here",
-		"soilName":                 "stuff here",
-
-		// DLINK Cam Stuff
-		"image":         "stuff here",
-		"statusMessage": "stuff here",
-		"mute":          "stuff here",
-		"hubactionMode": "stuff here",
-		"switch2":       "stuff here",
-		"switch3":       "stuff here",
-		"switch4":       "stuff here",
-		"switch5":       "stuff here",
-		"switch6":       "stuff here",
-		"captureTime":   "stuff here",
-		"camera":        "stuff here",
-		"settings":      "stuff here",
-		"stream":        "stuff here",
-		"clip":          "stuff here",
-
-		// Arlo Cams Stuff
-		"nightVision":        "stuff here",
-		"powerManagement":    "stuff here",
-		"desiredCameraState": "stuff here",
-		"ruleId":             "stuff here",
-		"sound":              "stuff here",
-		"invertImage":        "stuff here",
-		"offline":            "stuff here",
-		"rssi":               "stuff here",
-		"active":             "stuff here",
-		"timeLastRefresh":    "stuff here",
-		"lqi":                "stuff here",
-		"clipStatus":         "stuff here",
-
-		// Room Stuff
-		"occupancy":        "stuff here",
-		"occupancyIconURL": "stuff here",
-		"countdown":        "stuff here",
-
-		// Multisensor Stuff
-		"batteryStatus": "stuff here",
-		"tamper":        "stuff here",
-		"powerSource":   "stuff here",
-	}
-	metrics = map[string]*metric{
-		"alarm": {prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "alarm"),
-			"1 if the alarm is on.", []string{"id", "name"}, nil),
-			func(i interface{}) (f float64, e error) {
-				return valueOneOf(i, valOffOn)
-			}},
-
-		"alarmState": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "alarm_cleared"), "0 if the alarm is clear.",
-			[]string{"id", "name"}, nil), valueClear},
-
-		"battery": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "battery_percentage"),
-			"Percentage of battery remaining.", []string{"id", "name"}, nil), valueFloat},
-
-		// TODO fix this duplication
-		"carbonMonoxide": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "contact_closed"),
-			"1 if the contact is closed.", []string{"id", "name"}, nil), valueClear},
-
-		"contact": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "contact_closed"),
-			"1 if the contact is closed.", []string{"id", "name"}, nil),
-			func(i interface{}) (f float64, e error) {
-				return valueOneOf(i, valOpenClosed)
-			}},
-
-		"energy": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "energy_usage_joules"),
-			"Energy usage in joules.", []string{"id", "name"}, nil),
-			func(i interface{}) (f float64, e error) {
-				value, err := valueFloat(i)
-				if err != nil {
-					return 0, err
-				}
-				return value * 3600000, err
-			}},
-
-		"humidity": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "humidity_level"),
-			"Humidity Level.", []string{"id", "name"}, nil), valueFloat},
-
-		"fanSpeed": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "fan_level"),
-			"Fan Level.", []string{"id", "name"}, nil), valueFloat},
-
-		"illuminance": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "lux_level"),
-			"LUX Level.", []string{"id", "name"}, nil), valueFloat},
-
-		"level": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "level_percent"),
-			"Level.", []string{"id", "name"}, nil), valueFloat},
-
-		"lock": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "locked"),
-			"Is Locked.", []string{"id", "name"}, nil),
-			func(i interface{}) (f float64, e error) {
-				return valueOneOf(i, valLockedUnlocked)
-			}},
-
-		"motion": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "motion_detected"),
-			"1 if presence is detected.", []string{"id", "name"}, nil),
-			func(i interface{}) (f float64, e error) {
-				return valueOneOf(i, valInactiveActive)
-			}},
-
-		"power": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "power_usage_watts"),
-			"Current power usage in watts.", []string{"id", "name"}, nil), valueFloat},
-
-		"presence": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "presence_detected"),
-			"1 if presence is detected.", []string{"id", "name"}, nil),
-			func(i interface{}) (f float64, e error) {
-				return valueOneOf(i, valAbsentPresent)
-			}},
-
-		"pressure": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "pressure_pascals"),
-			"Current pressure in pascals.", []string{"id", "name"}, nil), valueFloat},
-
-		"smoke": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "smoke_detected"), "1 if smoke is detected.",
-			[]string{"id", "name"}, nil), valueClear},
-
-		"switch": {prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "switch_enabled"),
-			"1 if the switch is on.", []string{"id", "name"}, nil),
-			func(i interface{}) (f float64, e error) {
-				return valueOneOf(i, valOffOn)
-			}},
-
-		"temperature": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "temperature_fahrenheit"),
-			"Temperature in fahrenheit.", []string{"id", "name"}, nil), valueFloat},
-
-		"ultravioletIndex": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "ultraviolet_index"),
-			"Ultraviolet Index.", []string{"id", "name"}, nil), valueFloat},
-
-		// Tesla Stuff
-		"speed": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "speed_miles_per_hour"),
-			"Speed at Miles Per Hour.", []string{"id", "name"}, nil), valueFloat},
-
-		"heading": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "heading"),
-			"heading.", []string{"id", "name"}, nil), valueFloat},
-
-		"longitude": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "longitude"),
-			"longitude.", []string{"id", "name"}, nil), valueFloat},
-
-		"latitude": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "latitude"),
-			"latitude.", []string{"id", "name"}, nil), valueFloat},
-
-		"odometer": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "odometer"),
-			"odometer.", []string{"id", "name"}, nil), valueFloat},
-
-		"batteryRange": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "battery_range"),
-			"Range in Miles for Battery.", []string{"id", "name"}, nil), valueFloat},
-
-		// TBD
-		"healthStatus": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "healthStatus"),
-			"Health Status.", []string{"id", "name"}, nil), valueFloat},
-
-		"hue": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "hue"),
-			"Lighting Hue.", []string{"id", "name"}, nil), valueFloat},
-
-		"saturation": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "saturation"),
-			"Lighting Saturation.", []string{"id", "name"}, nil), valueFloat},
-
-		"whiteLevel": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "whiteLevel"),
-			"White Light Level.", []string{"id", "name"}, nil), valueFloat},
-
-		"checkInterval": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "checkInterval"),
-			"Check Interval.", []string{"id", "name"}, nil), valueFloat},
-
-		"colorTemperature": {prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "colorTemperature"),
-			"Color Temperature.", []string{"id", "name"}, nil), valueFloat},
-	}
+	scrapeCollectorDuration = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "smartthings_scrape_collector_duration_seconds",
+			Help: "Duration, in seconds, of the attribute fetch for a single device.",
+		},
+		[]string{"device", "hub"},
+	)
+	scrapeCollectorSuccess = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "smartthings_scrape_collector_success",
+			Help: "1 if the attribute fetch for a single device succeeded, 0 otherwise.",
+		},
+		[]string{"device", "hub"},
+	)
+	scrapeDuration = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "smartthings_scrape_duration_seconds",
+			Help: "Duration, in seconds, of the last complete Collect scrape.",
+		},
+		[]string{"hub"},
+	)
+	configReloadsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "smartthings_config_reloads_total",
+			Help: "Total number of times the metrics config was (re)loaded, labeled by outcome.",
+		},
+	)
 )
 
-type metric struct {
-	description *prometheus.Desc
-	valueMapper func(interface{}) (float64, error)
+// activeRegistry holds the metrics registry currently in use by all
+// Exporters. It's replaced wholesale on reload (SIGHUP or startup), guarded
+// by registryMu since Collect goroutines read it concurrently.
+var (
+	registryMu     sync.RWMutex
+	activeRegistry *metricsRegistry
+)
+
+// setActiveRegistry installs reg as the registry used by all future scrapes.
+func setActiveRegistry(reg *metricsRegistry) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	activeRegistry = reg
+}
+
+// getActiveRegistry returns the registry currently in use by all scrapes.
+func getActiveRegistry() *metricsRegistry {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return activeRegistry
 }
 
-// Exporter collects smartthings stats and exports them using the prometheus metrics package.
+// loadActiveRegistry builds the MetricsConfig for configPath (the built-in
+// defaults, optionally overlaid by the file at configPath) and installs it
+// as the active registry.
+func loadActiveRegistry(configPath string) error {
+	cfg := defaultMetricsConfig()
+	if configPath != "" {
+		override, err := LoadMetricsConfig(configPath)
+		if err != nil {
+			return err
+		}
+		cfg = mergeMetricsConfig(cfg, override)
+	}
+
+	reg, err := buildMetricsRegistry(cfg)
+	if err != nil {
+		return err
+	}
+	setActiveRegistry(reg)
+	return nil
+}
+
+// Exporter collects smartthings stats for a single hub and exports them using
+// the prometheus metrics package and/or, if otlp is set, an OpenTelemetry
+// metric pipeline.
 type Exporter struct {
 	client   *http.Client
 	endpoint string
+	hub      HubConfig
+	otlp     *otlpSink
+
+	scrapeConcurrency int
+	scrapeTimeout     time.Duration
 }
 
-// NewExporter returns an initialized Exporter.
-func NewExporter(oauthClient string, oauthToken *oauth2.Token) (*Exporter, error) {
+// NewExporter returns an initialized Exporter for the given hub. otlp may be
+// nil, in which case scraped values are only delivered to Prometheus.
+func NewExporter(hub HubConfig, oauthToken *oauth2.Token, otlp *otlpSink, scrapeConcurrency int, scrapeTimeout time.Duration) (*Exporter, error) {
 	// Create the oauth2.config object with no secret to use with the token we already have
-	config := gosmart.NewOAuthConfig(oauthClient, "")
+	config := gosmart.NewOAuthConfig(hub.OAuthClient, "")
 
 	// Create a client with the token and fetch endpoints URI.
 	ctx := context.Background()
@@ -361,17 +197,32 @@ func NewExporter(oauthClient string, oauthToken *oauth2.Token) (*Exporter, error
 		plog.Fatalf("Error verifying connection to endpoints URI %v: %v\n", endpoint, err)
 	}
 
+	if scrapeConcurrency <= 0 {
+		scrapeConcurrency = defaultScrapeConcurrency
+	}
+	if scrapeTimeout <= 0 {
+		scrapeTimeout = defaultScrapeTimeout
+	}
+
 	// Init our exporter.
 	return &Exporter{
-		client:   client,
-		endpoint: endpoint,
+		client:            client,
+		endpoint:          endpoint,
+		hub:               hub,
+		otlp:              otlp,
+		scrapeConcurrency: scrapeConcurrency,
+		scrapeTimeout:     scrapeTimeout,
 	}, nil
 }
 
 // Describe describes all the metrics ever exported by the SmartThings exporter. It
 // implements prometheus.Collector.
 func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
-	for _, m := range metrics {
+	for _, m := range getActiveRegistry().metrics {
+		if m.multi != nil {
+			m.multi.describe(ch)
+			continue
+		}
 		ch <- m.description
 	}
 }
@@ -379,91 +230,132 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 // Collect fetches the stats from configured SmartThings location and delivers them
 // as Prometheus metrics. It implements prometheus.Collector.
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
-	// Iterate over all devices and collect timeseries info.
-	devs, err := gosmart.GetAllDevices(e.client, e.endpoint)
+	e.scrape(ch)
+}
+
+// scrape fetches the list of devices and maps their attributes to metric
+// values, sending each one to ch (if non-nil) and to e.otlp (if configured).
+// ch is nil when scrape is driven by an --otlp.interval timer rather than a
+// Prometheus scrape. Devices are scraped concurrently, bounded by
+// e.scrapeConcurrency, with each device's attribute fetch subject to an
+// e.scrapeTimeout deadline so one unresponsive device can't stall the whole
+// scrape.
+func (e *Exporter) scrape(ch chan<- prometheus.Metric) {
+	start := time.Now()
+	defer func() {
+		scrapeDuration.WithLabelValues(e.hub.Name).Set(time.Since(start).Seconds())
+	}()
+
+	devs, err := gosmart.GetDevices(e.client, e.endpoint)
 	if err != nil {
 		plog.Errorf("Error reading list of devices from %v: %v\n", e.endpoint, err)
+		return
 	}
 
+	sem := make(chan struct{}, e.scrapeConcurrency)
+	var wg sync.WaitGroup
 	for _, dev := range devs {
-		plog.Debugf("Dev> %s Id:%s - Fetching Attributes => %d\n", dev.DisplayName, dev.ID, len(dev.Attributes))
+		if !e.hub.Matches(dev.DisplayName) {
+			plog.Debugf("Dev> %s Id:%s - Excluded by hub %q device filter\n", dev.DisplayName, dev.ID, e.hub.Name)
+			continue
+		}
 
-		for k, val := range dev.Attributes {
-			if val == nil {
-				val = ""
-			}
+		dev := dev
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			e.collectDevice(ch, dev)
+		}()
+	}
+	wg.Wait()
+}
 
-			var value float64
-			toDrop := metricsToDrop[k]
-			if toDrop != "" {
-				droppedMetric.Inc()
-				plog.Debugf("  Attr> '%s' [val=%v] - Dropped", k, val)
-				continue
-			}
+// collectDevice fetches the attributes for a single device, subject to
+// e.scrapeTimeout, and emits the corresponding Prometheus metrics.
+//
+// gosmart.GetDeviceInfo is synchronous and takes no context.Context, so the
+// timeout can't cancel the underlying HTTP call; it only bounds how long
+// collectDevice waits for it. A device that never responds leaks its
+// goroutine until the request eventually completes or fails on its own.
+func (e *Exporter) collectDevice(ch chan<- prometheus.Metric, dev gosmart.DeviceInfo) {
+	deviceStart := time.Now()
+
+	type fetchResult struct {
+		info *gosmart.DeviceInfo
+		err  error
+	}
+	fetched := make(chan fetchResult, 1)
+	go func() {
+		info, err := gosmart.GetDeviceInfo(e.client, e.endpoint, dev.ID)
+		fetched <- fetchResult{info, err}
+	}()
+
+	var info *gosmart.DeviceInfo
+	var err error
+	select {
+	case res := <-fetched:
+		info, err = res.info, res.err
+	case <-time.After(e.scrapeTimeout):
+		err = fmt.Errorf("timed out waiting %s for device info", e.scrapeTimeout)
+	}
 
-			//var metricDesc *prometheus.Desc
-			metric := metrics[k]
-			if metric == nil {
-				unknownMetric.Inc()
-				plog.Debugf("  Attr> '%s' [val=%v] - Unknown", k, val)
-				continue
-			}
-			value, err = metric.valueMapper(val)
-			plog.Debugf("  Attr> '%s' [val=%f] - %s", k, value, metric.description)
+	scrapeCollectorDuration.WithLabelValues(dev.ID, e.hub.Name).Set(time.Since(deviceStart).Seconds())
+	if err != nil {
+		scrapeCollectorSuccess.WithLabelValues(dev.ID, e.hub.Name).Set(0)
+		plog.Errorf("Error reading attributes for device %s (%s): %v\n", dev.DisplayName, dev.ID, err)
+		return
+	}
+	scrapeCollectorSuccess.WithLabelValues(dev.ID, e.hub.Name).Set(1)
+
+	attrs := info.Attributes
+	plog.Debugf("Dev> %s Id:%s - Fetching Attributes => %d\n", dev.DisplayName, dev.ID, len(attrs))
 
-			if err == nil {
-				ch <- prometheus.MustNewConstMetric(metric.description, prometheus.GaugeValue, value, dev.ID, dev.DisplayName)
-			} else {
+	registry := getActiveRegistry()
+	for k, val := range attrs {
+		if val == nil {
+			val = ""
+		}
+
+		var value float64
+		if registry.metricsToDrop[k] {
+			droppedMetric.Inc()
+			plog.Debugf("  Attr> '%s' [val=%v] - Dropped", k, val)
+			continue
+		}
+
+		metric := registry.metrics[k]
+		if metric == nil {
+			unknownMetric.Inc()
+			plog.Debugf("  Attr> '%s' [val=%v] - Unknown", k, val)
+			continue
+		}
+
+		if metric.multi != nil {
+			if err := metric.multi.emit(ch, e.otlp, dev, e.hub.Name, val); err != nil {
 				invalidMetric.Inc()
-				plog.Errorf("%s - '%s' [val=%f] - %v", dev.DisplayName, k, value, err)
+				plog.Errorf("%s - '%s' [val=%v] - %v", dev.DisplayName, k, val, err)
 			}
+			continue
 		}
-	}
-}
 
-// valueClear expects a string and returns 0 for "clear", 1 for anything else.
-// TODO: Expand this to properly identify non-clear conditions and return error
-// in case an unexpected value is found.
-func valueClear(v interface{}) (float64, error) {
-	val, ok := v.(string)
-	if !ok {
-		return 0.0, fmt.Errorf("invalid non-string argument %v", v)
-	}
-	if val == "clear" {
-		return 0.0, nil
-	}
-	return 1.0, nil
-}
+		value, err = metric.valueMapper(val)
+		plog.Debugf("  Attr> '%s' [val=%f] - %s", k, value, metric.description)
 
-// valueOneOf returns 0.0 if the value matches the first item
-// in the array, 1.0 if it matches the second, and an error if
-// nothing matches.
-func valueOneOf(v interface{}, options []string) (float64, error) {
-	val, ok := v.(string)
-	if !ok {
-		return 0.0, fmt.Errorf("invalid non-string argument %v", v)
-	}
-	if val == options[0] {
-		return 0.0, nil
-	}
-	if val == options[1] {
-		return 1.0, nil
-	}
-	return 0.0, fmt.Errorf("invalid option %q. Expected %q or %q", val, options[0], options[1])
-}
+		if err != nil {
+			invalidMetric.Inc()
+			plog.Errorf("%s - '%s' [val=%f] - %v", dev.DisplayName, k, value, err)
+			continue
+		}
 
-// valueFloat returns the float64 value of the value passed or
-// error if the value cannot be converted.
-func valueFloat(v interface{}) (float64, error) {
-	stringVal, ok := v.(string)
-	if ok && stringVal == "" {
-		return 0.0, nil
-	}
-	val, ok := v.(float64)
-	if !ok {
-		return 0.0, fmt.Errorf("invalid non floating-point argument %v", v)
+		if ch != nil {
+			ch <- prometheus.MustNewConstMetric(metric.description, prometheus.GaugeValue, value, dev.ID, dev.DisplayName, e.hub.Name)
+		}
+		if e.otlp != nil {
+			e.otlp.observe(metric.name, metric.help, value, dev, e.hub.Name)
+		}
 	}
-	return val, nil
 }
 
 func init() {
@@ -477,8 +369,21 @@ func init() {
 	monitorCommand = application.Command("start", "Start the smartthings_exporter.").Default().Action(monitor)
 	listenAddress = monitorCommand.Flag("web.listen-address", "Address to listen on for web interface and telemetry.").Default(":9499").String()
 	metricsPath = monitorCommand.Flag("web.telemetry-path", "Path under which to expose metrics.").Default("/metrics").String()
-	monitorOAuthClient = monitorCommand.Flag("smartthings.oauth-client", "Smartthings OAuth client ID.").Required().String()
-	monitorOAuthTokenFile = monitorCommand.Flag("smartthings.oauth-token.file", "File containing the Smartthings OAuth token.").Required().ExistingFile()
+	monitorOAuthClient = monitorCommand.Flag("smartthings.oauth-client", "Smartthings OAuth client ID. Mutually exclusive with --config.file.").String()
+	monitorOAuthTokenFile = monitorCommand.Flag("smartthings.oauth-token.file", "File containing the Smartthings OAuth token. Mutually exclusive with --config.file.").ExistingFile()
+	monitorConfigFile = monitorCommand.Flag("config.file", "YAML file defining multiple Smartthings hubs to scrape. Mutually exclusive with --smartthings.oauth-client/--smartthings.oauth-token.file.").ExistingFile()
+	monitorMetricsConfigFile = monitorCommand.Flag("metrics.config", "YAML file overriding/extending the built-in Smartthings attribute-to-metric mappings.").ExistingFile()
+	monitorScrapeConcurrency = monitorCommand.Flag("smartthings.scrape-concurrency", "Maximum number of devices to scrape concurrently.").Default("4").Int()
+	monitorScrapeTimeout = monitorCommand.Flag("smartthings.scrape-timeout", "Per-device timeout for fetching attributes from the Smartthings API.").Default("10s").Duration()
+
+	monitorOutputMode = monitorCommand.Flag("output.mode", "Where to send collected metrics: prometheus, otlp, or both.").Default(outputModePrometheus).Enum(outputModePrometheus, outputModeOTLP, outputModeBoth)
+	monitorOTLPEndpoint = monitorCommand.Flag("otlp.endpoint", "OTLP collector endpoint (host:port). Required when --output.mode is otlp or both.").String()
+	monitorOTLPHeaders = monitorCommand.Flag("otlp.headers", "Comma-separated key=value headers to send with every OTLP export.").String()
+	monitorOTLPProtocol = monitorCommand.Flag("otlp.protocol", "OTLP wire protocol to use: grpc or http.").Default(otlpProtocolGRPC).Enum(otlpProtocolGRPC, otlpProtocolHTTP)
+	monitorOTLPInterval = monitorCommand.Flag("otlp.interval", "How often to push metrics to the OTLP collector.").Default("1m").Duration()
+
+	checkMetricsConfigCommand = application.Command("metrics.config.check", "Validate a --metrics.config file and report unknown/duplicate mappings.").Action(checkMetricsConfig)
+	checkMetricsConfigFile = checkMetricsConfigCommand.Arg("file", "YAML metrics config file to validate.").Required().ExistingFile()
 }
 
 func main() {
@@ -518,33 +423,161 @@ func register(_ *kingpin.ParseContext) error {
 	return nil
 }
 
+// checkMetricsConfig validates a --metrics.config file against the built-in
+// defaults and reports unknown value types and duplicate attribute mappings.
+func checkMetricsConfig(_ *kingpin.ParseContext) error {
+	override, err := LoadMetricsConfig(*checkMetricsConfigFile)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return err
+	}
+
+	merged := mergeMetricsConfig(defaultMetricsConfig(), override)
+	if _, err := buildMetricsRegistry(merged); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return err
+	}
+
+	fmt.Printf("%s: OK (%d attribute mappings)\n", *checkMetricsConfigFile, len(override.Metrics))
+	return nil
+}
+
+// hubsFromFlags returns the set of hubs to scrape, either the single hub
+// described by the legacy --smartthings.oauth-client/--smartthings.oauth-token.file
+// flags or the hubs declared by --config.file.
+func hubsFromFlags() ([]HubConfig, error) {
+	if *monitorConfigFile != "" {
+		if *monitorOAuthClient != "" || *monitorOAuthTokenFile != "" {
+			return nil, fmt.Errorf("--config.file is mutually exclusive with --smartthings.oauth-client and --smartthings.oauth-token.file")
+		}
+		cfg, err := LoadConfig(*monitorConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		return cfg.Hubs, nil
+	}
+
+	if *monitorOAuthClient == "" || *monitorOAuthTokenFile == "" {
+		return nil, fmt.Errorf("either --config.file or both --smartthings.oauth-client and --smartthings.oauth-token.file must be set")
+	}
+	return []HubConfig{{
+		OAuthClient:    *monitorOAuthClient,
+		OAuthTokenFile: *monitorOAuthTokenFile,
+	}}, nil
+}
+
+// watchMetricsConfigReloads reloads the metrics registry from configPath
+// every time the process receives SIGHUP, so operators can pick up config
+// changes (new/changed attribute mappings) without restarting the exporter.
+func watchMetricsConfigReloads(configPath string) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for range sighup {
+			plog.Infoln("Received SIGHUP, reloading metrics config")
+			if err := loadActiveRegistry(configPath); err != nil {
+				plog.Errorf("Failed to reload metrics config: %v\n", err)
+				continue
+			}
+			configReloadsTotal.Inc()
+		}
+	}()
+}
+
+// pollOTLP scrapes exporter on a fixed interval so its values keep reaching
+// the OTLP pipeline even when nothing is scraping its /metrics endpoint.
+func pollOTLP(exporter *Exporter, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		exporter.scrape(nil)
+	}
+}
+
 func monitor(_ *kingpin.ParseContext) error {
 	plog.Infoln("Starting smartthings_exporter", version.Info())
 	plog.Infoln("Build context", version.BuildContext())
 
-	tokenFilePath, err := filepath.Abs(*monitorOAuthTokenFile)
+	hubs, err := hubsFromFlags()
 	if err != nil {
-		plog.Errorf("Failed to get absolution path to token file %s.\n", *monitorOAuthTokenFile)
+		plog.Errorln(err)
 		return err
 	}
 
-	token, err := gosmart.LoadToken(tokenFilePath)
-	if err != nil || !token.Valid() {
-		plog.Errorf("Failed to load Smartthings OAuth token from %s.\n", *monitorOAuthTokenFile)
+	if err := loadActiveRegistry(*monitorMetricsConfigFile); err != nil {
+		plog.Errorln(err)
 		return err
 	}
+	configReloadsTotal.Inc()
+	watchMetricsConfigReloads(*monitorMetricsConfigFile)
+
+	wantPrometheus := *monitorOutputMode == outputModePrometheus || *monitorOutputMode == outputModeBoth
+	wantOTLP := *monitorOutputMode == outputModeOTLP || *monitorOutputMode == outputModeBoth
+
+	var otlp *otlpSink
+	if wantOTLP {
+		if *monitorOTLPEndpoint == "" {
+			err := fmt.Errorf("--otlp.endpoint is required when --output.mode is %q or %q", outputModeOTLP, outputModeBoth)
+			plog.Errorln(err)
+			return err
+		}
+		provider, err := newOTLPMeterProvider(*monitorOTLPEndpoint, *monitorOTLPProtocol, *monitorOTLPHeaders, *monitorOTLPInterval)
+		if err != nil {
+			plog.Errorln(err)
+			return err
+		}
+		otlp = newOTLPSink(provider.Meter(meterName))
+	}
 
-	exporter, err := NewExporter(*monitorOAuthClient, token)
-	if err != nil {
-		plog.Fatalln(err)
-		return err
+	exporters := make(map[string]*Exporter, len(hubs))
+	for _, hub := range hubs {
+		tokenFilePath, err := filepath.Abs(hub.OAuthTokenFile)
+		if err != nil {
+			plog.Errorf("Failed to get absolution path to token file %s.\n", hub.OAuthTokenFile)
+			return err
+		}
+
+		token, err := gosmart.LoadToken(tokenFilePath)
+		if err != nil || !token.Valid() {
+			plog.Errorf("Failed to load Smartthings OAuth token from %s.\n", hub.OAuthTokenFile)
+			return err
+		}
+
+		exporter, err := NewExporter(hub, token, otlp, *monitorScrapeConcurrency, *monitorScrapeTimeout)
+		if err != nil {
+			plog.Fatalln(err)
+			return err
+		}
+		exporters[hub.Name] = exporter
+		if wantPrometheus {
+			prometheus.MustRegister(exporter)
+		}
+		if wantOTLP {
+			go pollOTLP(exporter, *monitorOTLPInterval)
+		}
 	}
+
 	prometheus.MustRegister(invalidMetric)
 	prometheus.MustRegister(unknownMetric)
 	prometheus.MustRegister(droppedMetric)
-	prometheus.MustRegister(exporter)
+	prometheus.MustRegister(scrapeCollectorDuration)
+	prometheus.MustRegister(scrapeCollectorSuccess)
+	prometheus.MustRegister(scrapeDuration)
+	prometheus.MustRegister(configReloadsTotal)
 
 	http.Handle(*metricsPath, promhttp.Handler())
+	http.HandleFunc("/probe", func(w http.ResponseWriter, r *http.Request) {
+		target := r.URL.Query().Get("target")
+		exporter, ok := exporters[target]
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown target %q", target), http.StatusBadRequest)
+			return
+		}
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(exporter)
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	})
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		_, _ = w.Write([]byte(`<html>
 			        <head><title>SmartThings Exporter</title></head>